@@ -0,0 +1,87 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package azidentity-static-source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// ChainedWorkloadCredentialOptions contains optional parameters for ChainedWorkloadCredential.
+type ChainedWorkloadCredentialOptions struct {
+	// RetryInterval controls how long ChainedWorkloadCredential keeps using a source that previously succeeded
+	// before re-probing the full chain from the start. The zero value disables re-probing: once a source
+	// succeeds, it's used for every subsequent GetToken call until it itself returns an error.
+	RetryInterval time.Duration
+}
+
+// ChainedWorkloadCredential tries a sequence of credentials in order until one succeeds, then caches that choice
+// for subsequent calls. It's intended to pair a WorkloadIdentityFederationCredential with fallback credentials
+// such as ManagedIdentityCredential or AzureCLICredential, analogous to azidentity.ChainedTokenCredential.
+type ChainedWorkloadCredential struct {
+	sources       []azcore.TokenCredential
+	retryInterval time.Duration
+
+	mtx       sync.RWMutex
+	lastGood  int // index into sources of the last credential to succeed, or -1
+	lastProbe time.Time
+}
+
+// NewChainedWorkloadIdentityCredential constructs a ChainedWorkloadCredential from sources, which are tried in
+// order on the first call to GetToken and whenever the cached source stops succeeding. Set options to configure
+// re-probing; pass nil to accept defaults.
+func NewChainedWorkloadIdentityCredential(sources []azcore.TokenCredential, options *ChainedWorkloadCredentialOptions) (*ChainedWorkloadCredential, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("NewChainedWorkloadIdentityCredential requires at least one source")
+	}
+	if options == nil {
+		options = &ChainedWorkloadCredentialOptions{}
+	}
+	return &ChainedWorkloadCredential{sources: sources, retryInterval: options.RetryInterval, lastGood: -1}, nil
+}
+
+// GetToken requests an access token from the first source that succeeds, starting with the cached source from a
+// previous successful call unless it's due for re-probing or itself returns an error.
+func (c *ChainedWorkloadCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.mtx.RLock()
+	cached, lastProbe := c.lastGood, c.lastProbe
+	c.mtx.RUnlock()
+	useCached := cached >= 0 && (c.retryInterval <= 0 || time.Since(lastProbe) < c.retryInterval)
+
+	var errs []string
+	if useCached {
+		tok, err := c.sources[cached].GetToken(ctx, opts)
+		if err == nil {
+			return tok, nil
+		}
+		errs = append(errs, fmt.Sprintf("%T: %s", c.sources[cached], err))
+	}
+
+	for i, source := range c.sources {
+		if useCached && i == cached {
+			continue // already tried above
+		}
+		tok, err := source.GetToken(ctx, opts)
+		if err == nil {
+			c.mtx.Lock()
+			c.lastGood = i
+			c.lastProbe = time.Now()
+			c.mtx.Unlock()
+			return tok, nil
+		}
+		errs = append(errs, fmt.Sprintf("%T: %s", source, err))
+	}
+
+	c.mtx.Lock()
+	c.lastGood = -1
+	c.mtx.Unlock()
+	return azcore.AccessToken{}, fmt.Errorf("ChainedWorkloadCredential: no credential succeeded\n\t%s", strings.Join(errs, "\n\t"))
+}
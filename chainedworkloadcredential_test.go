@@ -0,0 +1,145 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package azidentity-static-source
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// fakeCredential is a minimal azcore.TokenCredential for testing ChainedWorkloadCredential.
+type fakeCredential struct {
+	calls int
+	err   error
+	token azcore.AccessToken
+}
+
+func (f *fakeCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	f.calls++
+	if f.err != nil {
+		return azcore.AccessToken{}, f.err
+	}
+	return f.token, nil
+}
+
+func TestNewChainedWorkloadIdentityCredential_NoSources(t *testing.T) {
+	if _, err := NewChainedWorkloadIdentityCredential(nil, nil); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestChainedWorkloadCredential_FirstSourceSucceeds(t *testing.T) {
+	first := &fakeCredential{token: azcore.AccessToken{Token: "first"}}
+	second := &fakeCredential{token: azcore.AccessToken{Token: "second"}}
+	c, err := NewChainedWorkloadIdentityCredential([]azcore.TokenCredential{first, second}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tok, err := c.GetToken(context.Background(), policy.TokenRequestOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tok.Token != "first" {
+		t.Fatalf("got token %q, want %q", tok.Token, "first")
+	}
+	if second.calls != 0 {
+		t.Fatalf("expected second source not to be tried, got %d calls", second.calls)
+	}
+}
+
+func TestChainedWorkloadCredential_FallsBackOnFailure(t *testing.T) {
+	first := &fakeCredential{err: errors.New("AADSTS70021: invalid assertion")}
+	second := &fakeCredential{token: azcore.AccessToken{Token: "second"}}
+	c, err := NewChainedWorkloadIdentityCredential([]azcore.TokenCredential{first, second}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tok, err := c.GetToken(context.Background(), policy.TokenRequestOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tok.Token != "second" {
+		t.Fatalf("got token %q, want %q", tok.Token, "second")
+	}
+}
+
+func TestChainedWorkloadCredential_CachesSuccessfulSource(t *testing.T) {
+	first := &fakeCredential{err: errors.New("unavailable")}
+	second := &fakeCredential{token: azcore.AccessToken{Token: "second"}}
+	c, err := NewChainedWorkloadIdentityCredential([]azcore.TokenCredential{first, second}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetToken(context.Background(), policy.TokenRequestOptions{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if first.calls != 1 {
+		t.Fatalf("expected the failing source to be tried once, got %d calls", first.calls)
+	}
+	if second.calls != 3 {
+		t.Fatalf("expected the cached source to be used every call, got %d calls", second.calls)
+	}
+}
+
+func TestChainedWorkloadCredential_ReprobesAfterCachedSourceFails(t *testing.T) {
+	first := &fakeCredential{token: azcore.AccessToken{Token: "first"}}
+	c, err := NewChainedWorkloadIdentityCredential([]azcore.TokenCredential{first}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.GetToken(context.Background(), policy.TokenRequestOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first.err = errors.New("expired")
+	if _, err := c.GetToken(context.Background(), policy.TokenRequestOptions{}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if first.calls != 2 {
+		t.Fatalf("expected the cached source to be retried on failure, got %d calls", first.calls)
+	}
+}
+
+func TestChainedWorkloadCredential_ReprobesAfterRetryInterval(t *testing.T) {
+	first := &fakeCredential{token: azcore.AccessToken{Token: "first"}}
+	second := &fakeCredential{token: azcore.AccessToken{Token: "second"}}
+	c, err := NewChainedWorkloadIdentityCredential([]azcore.TokenCredential{first, second}, &ChainedWorkloadCredentialOptions{RetryInterval: time.Minute})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.GetToken(context.Background(), policy.TokenRequestOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// simulate the retry interval elapsing
+	c.lastProbe = time.Now().Add(-2 * time.Minute)
+	if _, err := c.GetToken(context.Background(), policy.TokenRequestOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.calls != 2 {
+		t.Fatalf("expected the chain to be re-probed from the start, got %d calls on first source", first.calls)
+	}
+}
+
+func TestChainedWorkloadCredential_CombinesErrorsWhenAllFail(t *testing.T) {
+	first := &fakeCredential{err: errors.New("first failure")}
+	second := &fakeCredential{err: errors.New("second failure")}
+	c, err := NewChainedWorkloadIdentityCredential([]azcore.TokenCredential{first, second}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = c.GetToken(context.Background(), policy.TokenRequestOptions{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "first failure") || !strings.Contains(err.Error(), "second failure") {
+		t.Fatalf("expected combined error to mention both failures, got: %v", err)
+	}
+}
@@ -5,8 +5,13 @@ package azidentity-static-source
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,27 +19,75 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"golang.org/x/oauth2"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
-	azureClientID            = "AZURE_CLIENT_ID"
-	azureTenantID            = "AZURE_TENANT_ID"
-	azureFederatedTokentoken = "AZURE_FEDERATED_TOKEN"
+	azureClientID                   = "AZURE_CLIENT_ID"
+	azureTenantID                   = "AZURE_TENANT_ID"
+	azureFederatedTokentoken        = "AZURE_FEDERATED_TOKEN"
+	azureFederatedTokenFile         = "AZURE_FEDERATED_TOKEN_FILE"
+	azureAdditionallyAllowedTenants = "AZURE_ADDITIONALLY_ALLOWED_TENANTS"
+
+	// assertionRefreshTimeout bounds how long a single assertion fetch (file read, token source call, etc.) may
+	// run, whether triggered by a GetToken call or by the proactive background refresh.
+	assertionRefreshTimeout = 30 * time.Second
 )
 
 var (
 	errorClientIDNotSpecified = errors.New("no client ID specified. Check pod configuration or set ClientID in the options")
-	errorTokenNotSpecified    = errors.New("no token specified. Check pod configuration or set FederatedToken in the options")
+	errorTokenNotSpecified    = errors.New("no token specified. Check pod configuration or set FederatedToken or TokenFilePath in the options")
 	errorTenantIDNotSpecified = errors.New("no tenant ID specified. Check pod configuration or set TenantID in the options")
 )
 
+// InsecureAuthorityHostError indicates ClientOptions.Cloud.ActiveDirectoryAuthorityHost doesn't use TLS. Sending
+// a federated JWT assertion or bearer token to such an endpoint risks leaking it, so the credential refuses to
+// authenticate against one.
+type InsecureAuthorityHostError struct {
+	// Host is the rejected authority host.
+	Host string
+}
+
+func (e *InsecureAuthorityHostError) Error() string {
+	return fmt.Sprintf("ActiveDirectoryAuthorityHost %q must use https, or be localhost for testing", e.Host)
+}
+
+// validateAuthorityHost returns an *InsecureAuthorityHostError if host is set and uses neither https nor a
+// localhost or 127.0.0.1 hostname.
+func validateAuthorityHost(host string) error {
+	if host == "" {
+		return nil
+	}
+	raw := host
+	if !strings.Contains(raw, "://") {
+		// host has no scheme, e.g. "localhost:8443"; add one so url.Parse can extract its hostname
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return &InsecureAuthorityHostError{Host: host}
+	}
+	if strings.EqualFold(u.Scheme, "https") || u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1" {
+		return nil
+	}
+	return &InsecureAuthorityHostError{Host: host}
+}
+
 // WorkloadIdentityFederationCredential supports any OIDC-compliant identity provider that supplies a JWT token.
 type WorkloadIdentityFederationCredential struct {
-	assertion string
-	token     oauth2.Token
-	cred      *azidentity.ClientAssertionCredential
-	expires   time.Time
-	mtx       *sync.RWMutex
+	assertion      string
+	token          oauth2.Token
+	tokenFilePath  string
+	getAssertionFn func(context.Context) (string, time.Time, error)
+	cred           *azidentity.ClientAssertionCredential
+	expires        time.Time
+	issuedAt       time.Time
+	rawExpiry      time.Time
+	mtx            *sync.RWMutex
+	sf             singleflight.Group
+	refreshed      chan struct{}
+	stopCh         chan struct{}
+	stopOnce       sync.Once
 }
 
 // WorkloadIdentityFederationCredentialOptions contains optional parameters for WorkloadIdentityFederationCredential.
@@ -43,7 +96,8 @@ type WorkloadIdentityFederationCredentialOptions struct {
 
 	// AdditionallyAllowedTenants specifies additional tenants for which the credential may acquire tokens.
 	// Add the wildcard value "*" to allow the credential to acquire tokens for any tenant in which the
-	// application is registered.
+	// application is registered. This is merged with the semicolon-delimited list of tenants in the
+	// environment variable AZURE_ADDITIONALLY_ALLOWED_TENANTS, if set.
 	AdditionallyAllowedTenants []string
 	// ClientID of the service principal. Defaults to the value of the environment variable AZURE_CLIENT_ID.
 	ClientID string
@@ -56,6 +110,23 @@ type WorkloadIdentityFederationCredentialOptions struct {
 	TenantID string
 	// FederatedToken is the federated token to use for authentication. Defaults to the value of the environment variable AZURE_FEDERATED_TOKEN.
 	FederatedToken oauth2.Token
+	// TokenFilePath is the path of a file containing the federated token. Defaults to the value of the environment
+	// variable AZURE_FEDERATED_TOKEN_FILE. When set, the file is re-read whenever the cached assertion is stale,
+	// which allows picking up tokens rotated by the Azure workload identity webhook without restarting the process.
+	// The cached assertion's expiry is taken from the token's "exp" claim. TokenFilePath takes precedence over
+	// FederatedToken.
+	TokenFilePath string
+	// AssertionSource supplies the JWT assertion dynamically, e.g. from a third-party OIDC provider such as
+	// GitHub Actions, GitLab CI, SPIFFE/SPIRE, or Vault JWT auth. It's called whenever the cached assertion is
+	// stale. AssertionSource takes precedence over TokenFilePath and FederatedToken. Set GetAssertion instead if
+	// the source needs a context.
+	AssertionSource oauth2.TokenSource
+	// GetAssertion is a functional alternative to AssertionSource for callers that need a context to mint an
+	// assertion. The context it receives is not the GetToken caller's: it's detached from any one caller and
+	// bounded by a 30 second timeout, because concurrent callers share a single fetch. A cancelled GetToken call
+	// returns early, but doesn't cancel the in-flight fetch. GetAssertion takes precedence over AssertionSource,
+	// TokenFilePath, and FederatedToken.
+	GetAssertion func(context.Context) (string, time.Time, error)
 }
 
 // NewWorkloadIdentityFederationCredential constructs a WorkloadIdentityFederationCredential. Service principal configuration is read
@@ -71,11 +142,27 @@ func NewWorkloadIdentityFederationCredential(options *WorkloadIdentityFederation
 			return nil, errorClientIDNotSpecified
 		}
 	}
+	getAssertionFn := options.GetAssertion
+	if getAssertionFn == nil && options.AssertionSource != nil {
+		src := options.AssertionSource
+		getAssertionFn = func(context.Context) (string, time.Time, error) {
+			tok, err := src.Token()
+			if err != nil {
+				return "", time.Time{}, err
+			}
+			return tok.AccessToken, tok.Expiry, nil
+		}
+	}
+	tokenFilePath := options.TokenFilePath
+	if tokenFilePath == "" {
+		tokenFilePath = os.Getenv(azureFederatedTokenFile)
+	}
 	token := options.FederatedToken
 	if token.AccessToken == "" {
-		if token.AccessToken, ok = os.LookupEnv(azureFederatedTokentoken); !ok || token.AccessToken == "" {
-			return nil, errorTokenNotSpecified
-		}
+		token.AccessToken, _ = os.LookupEnv(azureFederatedTokentoken)
+	}
+	if getAssertionFn == nil && token.AccessToken == "" && tokenFilePath == "" {
+		return nil, errorTokenNotSpecified
 	}
 	tenantID := options.TenantID
 	if tenantID == "" {
@@ -83,9 +170,19 @@ func NewWorkloadIdentityFederationCredential(options *WorkloadIdentityFederation
 			return nil, errorTenantIDNotSpecified
 		}
 	}
-	w := WorkloadIdentityFederationCredential{token: token, mtx: &sync.RWMutex{}}
+	if err := validateAuthorityHost(options.ClientOptions.Cloud.ActiveDirectoryAuthorityHost); err != nil {
+		return nil, err
+	}
+	w := WorkloadIdentityFederationCredential{
+		token:          token,
+		tokenFilePath:  tokenFilePath,
+		getAssertionFn: getAssertionFn,
+		mtx:            &sync.RWMutex{},
+		refreshed:      make(chan struct{}, 1),
+		stopCh:         make(chan struct{}),
+	}
 	caco := azidentity.ClientAssertionCredentialOptions{
-		AdditionallyAllowedTenants: options.AdditionallyAllowedTenants,
+		AdditionallyAllowedTenants: additionallyAllowedTenants(options.AdditionallyAllowedTenants),
 		ClientOptions:              options.ClientOptions,
 		DisableInstanceDiscovery:   options.DisableInstanceDiscovery,
 	}
@@ -95,30 +192,188 @@ func NewWorkloadIdentityFederationCredential(options *WorkloadIdentityFederation
 	}
 
 	w.cred = cred
+	go w.runProactiveRefresh()
 	return &w, nil
 }
 
+// Close stops the credential's background refresh goroutine. Call this when the credential is no longer needed
+// to avoid leaking the goroutine in long-lived processes.
+func (w *WorkloadIdentityFederationCredential) Close() {
+	w.stopOnce.Do(func() { close(w.stopCh) })
+}
+
 // GetToken requests an access token from Azure Active Directory. Azure SDK clients call this method automatically.
 func (w *WorkloadIdentityFederationCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
 	return w.cred.GetToken(ctx, opts)
 }
 
 // getAssertion returns the specified token, which is expected to be a valid JWT token. The token is cached and reused until it expires.
-func (w *WorkloadIdentityFederationCredential) getAssertion(context.Context) (string, error) {
+func (w *WorkloadIdentityFederationCredential) getAssertion(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
 	w.mtx.RLock()
-	if w.expires.Before(time.Now()) {
-		// ensure only one goroutine at a time updates the assertion
+	assertion, expires := w.assertion, w.expires
+	w.mtx.RUnlock()
+	if expires.After(time.Now()) {
+		return assertion, nil
+	}
+	// singleflight ensures only one goroutine at a time fetches a new assertion; concurrent callers wait for
+	// and share its result instead of each performing their own (possibly I/O-bound) fetch. The fetch itself
+	// runs on a context detached from any one caller, bounded by its own timeout, so one caller's cancellation
+	// can't abort the refresh out from under every other caller coalesced onto it.
+	done := make(chan assertionResult, 1)
+	go func() {
+		v, err, _ := w.sf.Do("assertion", func() (interface{}, error) {
+			refreshCtx, cancel := context.WithTimeout(context.Background(), assertionRefreshTimeout)
+			defer cancel()
+			return w.refresh(refreshCtx)
+		})
+		if err != nil {
+			done <- assertionResult{err: err}
+			return
+		}
+		done <- assertionResult{assertion: v.(string)}
+	}()
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r := <-done:
+		return r.assertion, r.err
+	}
+}
+
+// assertionResult carries the outcome of a background assertion fetch back to a waiting getAssertion call.
+type assertionResult struct {
+	assertion string
+	err       error
+}
+
+// refresh fetches a new assertion from the configured source, caches it, and returns it. Callers go through
+// the singleflight group, as getAssertion and runProactiveRefresh do, so concurrent refreshes are coalesced.
+func (w *WorkloadIdentityFederationCredential) refresh(ctx context.Context) (string, error) {
+	var assertion string
+	var expiry time.Time
+	var err error
+	switch {
+	case w.getAssertionFn != nil:
+		assertion, expiry, err = w.getAssertionFn(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to get assertion: %w", err)
+		}
+	case w.tokenFilePath != "":
+		// Kubernetes rotates the projected service account token in place, so re-read it from disk
+		// whenever the cached assertion is stale rather than relying on a value captured at construction.
+		var b []byte
+		b, err = os.ReadFile(w.tokenFilePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token file %q: %w", w.tokenFilePath, err)
+		}
+		assertion = strings.TrimSpace(string(b))
+		expiry, err = parseJWTExpiry(assertion)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse token file %q: %w", w.tokenFilePath, err)
+		}
+	default:
+		assertion = w.token.AccessToken
+		expiry = w.token.Expiry
+	}
+
+	now := time.Now()
+	w.mtx.Lock()
+	w.assertion = assertion
+	// To be on the safe side, subtract 10 minutes from the token expiry time
+	w.expires = expiry.Add(-10 * time.Minute)
+	w.issuedAt = now
+	w.rawExpiry = expiry
+	w.mtx.Unlock()
+
+	select {
+	case w.refreshed <- struct{}{}:
+	default:
+	}
+	return assertion, nil
+}
+
+// runProactiveRefresh refreshes the cached assertion at 50% of its remaining lifetime, rather than waiting for
+// it to fall inside the 10-minute safety margin, so that a slow or failing refresh has time to be retried
+// before GetToken callers are affected. It runs until Close is called.
+func (w *WorkloadIdentityFederationCredential) runProactiveRefresh() {
+	for {
+		w.mtx.RLock()
+		issuedAt, rawExpiry := w.issuedAt, w.rawExpiry
 		w.mtx.RUnlock()
-		w.mtx.Lock()
-		defer w.mtx.Unlock()
-		// double check because another goroutine may have acquired the write lock first and done the update
-		if now := time.Now(); w.expires.Before(now) {
-			w.assertion = w.token.AccessToken
-			// To be on the safe side, subtract 10 minutes from the token expiry time
-			w.expires = w.token.Expiry.Add(-10 * time.Minute)
+
+		wait := time.Minute
+		if !rawExpiry.IsZero() {
+			if d := time.Until(issuedAt.Add(rawExpiry.Sub(issuedAt) / 2)); d > 0 {
+				wait = d
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-w.stopCh:
+			timer.Stop()
+			return
+		case <-w.refreshed:
+			timer.Stop()
+			// An assertion was just fetched, possibly by getAssertion; recompute the wait against it.
+		case <-timer.C:
+			// Call refresh directly rather than getAssertion: at this point the cached assertion is still
+			// within its validity window (that's the point of refreshing early), so getAssertion's cache
+			// check would just return it unfetched. Go through the singleflight group so this doesn't race
+			// a concurrent GetToken-triggered refresh.
+			ctx, cancel := context.WithTimeout(context.Background(), assertionRefreshTimeout)
+			w.sf.Do("assertion", func() (interface{}, error) { return w.refresh(ctx) })
+			cancel()
+		}
+	}
+}
+
+// parseJWTExpiry returns the time encoded in a JWT's "exp" claim, without verifying the token's signature.
+func parseJWTExpiry(jwt string) (time.Time, error) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("malformed JWT: expected 3 dot-separated parts")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode JWT payload: %w", err)
+	}
+	claims := struct {
+		Expiry int64 `json:"exp"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("failed to unmarshal JWT claims: %w", err)
+	}
+	if claims.Expiry == 0 {
+		return time.Time{}, errors.New(`JWT has no "exp" claim`)
+	}
+	return time.Unix(claims.Expiry, 0), nil
+}
+
+// additionallyAllowedTenants merges tenants with the semicolon-delimited list of tenants in the environment
+// variable AZURE_ADDITIONALLY_ALLOWED_TENANTS, if set, giving precedence to the values already in tenants.
+func additionallyAllowedTenants(tenants []string) []string {
+	v, ok := os.LookupEnv(azureAdditionallyAllowedTenants)
+	if !ok {
+		return tenants
+	}
+	merged := tenants
+	for _, t := range strings.Split(v, ";") {
+		if t = strings.TrimSpace(t); t != "" && !contains(merged, t) {
+			merged = append(merged, t)
+		}
+	}
+	return merged
+}
+
+// contains reports whether s contains v.
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
 		}
-	} else {
-		defer w.mtx.RUnlock()
 	}
-	return w.assertion, nil
+	return false
 }
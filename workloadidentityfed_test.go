@@ -0,0 +1,109 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT License.
+
+package azidentity-static-source
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+// makeJWT builds a minimal unsigned JWT with the given claims payload, for testing parseJWTExpiry.
+func makeJWT(t *testing.T, payload string) string {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	return header + "." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".signature"
+}
+
+func TestParseJWTExpiry(t *testing.T) {
+	t.Run("valid exp claim", func(t *testing.T) {
+		want := time.Unix(1700000000, 0)
+		jwt := makeJWT(t, `{"exp":1700000000,"sub":"test"}`)
+		got, err := parseJWTExpiry(jwt)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !got.Equal(want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+
+	for _, test := range []struct {
+		name string
+		jwt  string
+	}{
+		{name: "too few parts", jwt: "header.payload"},
+		{name: "too many parts", jwt: "header.payload.signature.extra"},
+		{name: "payload isn't base64", jwt: "header.not!base64url.signature"},
+		{name: "payload isn't JSON", jwt: "header." + base64.RawURLEncoding.EncodeToString([]byte("not json")) + ".signature"},
+		{name: "missing exp claim", jwt: "header." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"test"}`)) + ".signature"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parseJWTExpiry(test.jwt); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestAdditionallyAllowedTenants(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		tenants []string
+		env     string
+		envSet  bool
+		want    []string
+	}{
+		{name: "env unset returns options unchanged", tenants: []string{"a"}, want: []string{"a"}},
+		{name: "env set, no options", env: "a;b", envSet: true, want: []string{"a", "b"}},
+		{name: "env merges after options", tenants: []string{"a"}, env: "b;c", envSet: true, want: []string{"a", "b", "c"}},
+		{name: "options take precedence over duplicates", tenants: []string{"a"}, env: "a;b", envSet: true, want: []string{"a", "b"}},
+		{name: "env entries are trimmed and empty entries dropped", tenants: []string{"a"}, env: " b ;; c ", envSet: true, want: []string{"a", "b", "c"}},
+		{name: "wildcard is just another tenant value", tenants: []string{"a"}, env: "*", envSet: true, want: []string{"a", "*"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if test.envSet {
+				t.Setenv(azureAdditionallyAllowedTenants, test.env)
+			}
+			got := additionallyAllowedTenants(test.tenants)
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("got %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateAuthorityHost(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		host    string
+		wantErr bool
+	}{
+		{name: "empty is allowed", host: ""},
+		{name: "https", host: "https://login.microsoftonline.com"},
+		{name: "https is case insensitive", host: "HTTPS://login.microsoftonline.com"},
+		{name: "http is rejected", host: "http://login.microsoftonline.com", wantErr: true},
+		{name: "localhost with scheme", host: "http://localhost:8443"},
+		{name: "localhost without scheme", host: "localhost:8443"},
+		{name: "bare localhost", host: "localhost"},
+		{name: "loopback IP without scheme", host: "127.0.0.1:8443"},
+		{name: "non-localhost without scheme is rejected", host: "login.microsoftonline.com", wantErr: true},
+		{name: "non-https, non-localhost is rejected", host: "http://evil.example.com", wantErr: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateAuthorityHost(test.host)
+			if test.wantErr && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !test.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}